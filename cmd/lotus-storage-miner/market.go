@@ -10,6 +10,7 @@ import (
 	"path/filepath"
 	"sort"
 	"strconv"
+	"strings"
 	"text/tabwriter"
 	"time"
 
@@ -22,14 +23,17 @@ import (
 	"github.com/urfave/cli/v2"
 	"golang.org/x/xerrors"
 
+	"github.com/filecoin-project/go-address"
 	cborutil "github.com/filecoin-project/go-cbor-util"
 	datatransfer "github.com/filecoin-project/go-data-transfer"
 	"github.com/filecoin-project/go-fil-markets/storagemarket"
 	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
 
 	"github.com/filecoin-project/lotus/build"
 	"github.com/filecoin-project/lotus/chain/types"
 	lcli "github.com/filecoin-project/lotus/cli"
+	"github.com/filecoin-project/lotus/markets/dealfilter"
 )
 
 var CidBaseFlag = cli.StringFlag{
@@ -335,6 +339,7 @@ var storageDealsCmd = &cli.Command{
 	Subcommands: []*cli.Command{
 		dealsImportDataCmd,
 		dealsListCmd,
+		dealsStatsCmd,
 		storageDealSelectionCmd,
 		setAskCmd,
 		getAskCmd,
@@ -382,11 +387,45 @@ var dealsListCmd = &cli.Command{
 		&cli.BoolFlag{
 			Name:    "verbose",
 			Aliases: []string{"v"},
+			Usage:   "print verbose deal details, including the client's proposal label",
 		},
 		&cli.BoolFlag{
 			Name:  "watch",
 			Usage: "watch deal updates in real-time, rather than a one time list",
 		},
+		&cli.StringFlag{
+			Name:  "state",
+			Usage: "only list deals in the given state (e.g. StorageDealActive or Active)",
+		},
+		&cli.StringFlag{
+			Name:  "client",
+			Usage: "only list deals proposed by the given client address",
+		},
+		&cli.Uint64Flag{
+			Name:  "sector",
+			Usage: "only list deals in the given sector",
+		},
+		&cli.StringFlag{
+			Name:  "since",
+			Usage: "only list deals created at or after the given time (RFC3339 timestamp, or duration ago e.g. 24h)",
+		},
+		&cli.StringFlag{
+			Name:  "until",
+			Usage: "only list deals created before the given time (RFC3339 timestamp, or duration ago e.g. 24h)",
+		},
+		&cli.StringFlag{
+			Name:  "sort",
+			Usage: "order deals by creation time or by price per epoch: age, price",
+			Value: "age",
+		},
+		&cli.IntFlag{
+			Name:  "offset",
+			Usage: "skip the given number of newest (or highest priced, with --sort price) deals",
+		},
+		&cli.IntFlag{
+			Name:  "limit",
+			Usage: "only list the given number of newest (or highest priced, with --sort price) deals (0 = no limit)",
+		},
 	},
 	Action: func(cctx *cli.Context) error {
 		api, closer, err := lcli.GetStorageMinerAPI(cctx)
@@ -397,6 +436,21 @@ var dealsListCmd = &cli.Command{
 
 		ctx := lcli.DaemonContext(cctx)
 
+		filter, err := newStorageDealsFilter(cctx)
+		if err != nil {
+			return err
+		}
+
+		if filter.client != address.Undef {
+			fapi, fcloser, err := lcli.GetFullNodeAPI(cctx)
+			if err != nil {
+				return err
+			}
+			defer fcloser()
+
+			filter.resolver = fapi
+		}
+
 		deals, err := api.MarketListIncompleteDeals(ctx)
 		if err != nil {
 			return err
@@ -415,7 +469,7 @@ var dealsListCmd = &cli.Command{
 				tm.Clear()
 				tm.MoveCursor(1, 1)
 
-				err = outputStorageDeals(tm.Output, deals, verbose)
+				err = outputStorageDeals(tm.Output, filter.apply(ctx, deals), verbose)
 				if err != nil {
 					return err
 				}
@@ -441,19 +495,202 @@ var dealsListCmd = &cli.Command{
 			}
 		}
 
-		return outputStorageDeals(os.Stdout, deals, verbose)
+		return outputStorageDeals(os.Stdout, filter.apply(ctx, deals), verbose)
 	},
 }
 
-func outputStorageDeals(out io.Writer, deals []storagemarket.MinerDeal, verbose bool) error {
-	sort.Slice(deals, func(i, j int) bool {
-		return deals[i].CreationTime.Time().Before(deals[j].CreationTime.Time())
-	})
+type storageDealsFilter struct {
+	state  *storagemarket.StorageDealStatus
+	client address.Address
+	sector *abi.SectorNumber
+	since  time.Time
+	until  time.Time
+	sortBy string
+	offset int
+	limit  int
+
+	// resolver is used to compare client addresses by their ID addresses;
+	// only required when filtering by client
+	resolver  dealfilter.AddressResolver
+	clientIDs map[address.Address]address.Address
+}
+
+func newStorageDealsFilter(cctx *cli.Context) (*storageDealsFilter, error) {
+	f := &storageDealsFilter{
+		sortBy:    cctx.String("sort"),
+		offset:    cctx.Int("offset"),
+		limit:     cctx.Int("limit"),
+		clientIDs: map[address.Address]address.Address{},
+	}
 
+	switch f.sortBy {
+	case "age", "price":
+	default:
+		return nil, xerrors.Errorf("unknown sort order %q, expected age or price", f.sortBy)
+	}
+
+	if f.offset < 0 {
+		return nil, xerrors.Errorf("offset must not be negative")
+	}
+
+	if f.limit < 0 {
+		return nil, xerrors.Errorf("limit must not be negative")
+	}
+
+	if cctx.IsSet("state") {
+		name := strings.ToLower(cctx.String("state"))
+		for st, stName := range storagemarket.DealStates {
+			stName = strings.ToLower(stName)
+			if name == stName || "storagedeal"+name == stName {
+				st := st
+				f.state = &st
+				break
+			}
+		}
+		if f.state == nil {
+			return nil, xerrors.Errorf("unknown deal state: %s", cctx.String("state"))
+		}
+	}
+
+	if cctx.IsSet("client") {
+		client, err := address.NewFromString(cctx.String("client"))
+		if err != nil {
+			return nil, xerrors.Errorf("parsing client address: %w", err)
+		}
+		f.client = client
+	}
+
+	if cctx.IsSet("sector") {
+		sector := abi.SectorNumber(cctx.Uint64("sector"))
+		f.sector = &sector
+	}
+
+	now := time.Now()
+	for _, tf := range []struct {
+		name string
+		out  *time.Time
+	}{
+		{"since", &f.since},
+		{"until", &f.until},
+	} {
+		if !cctx.IsSet(tf.name) {
+			continue
+		}
+
+		t, err := parseDealTime(cctx.String(tf.name), now)
+		if err != nil {
+			return nil, xerrors.Errorf("parsing --%s: %w", tf.name, err)
+		}
+		*tf.out = t
+	}
+
+	if !f.since.IsZero() && !f.until.IsZero() && !f.since.Before(f.until) {
+		return nil, xerrors.Errorf("--since must be before --until")
+	}
+
+	return f, nil
+}
+
+// parseDealTime parses either an RFC3339 timestamp or a duration, which is
+// interpreted as that long before now
+func parseDealTime(s string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+
+	return time.Parse(time.RFC3339, s)
+}
+
+// apply returns the deals matching the filter in ascending sort order. Offset
+// and limit select deals counting from the end of that order, so by default
+// the newest deals are kept.
+func (f *storageDealsFilter) apply(ctx context.Context, deals []storagemarket.MinerDeal) []storagemarket.MinerDeal {
+	out := make([]storagemarket.MinerDeal, 0, len(deals))
+	for _, deal := range deals {
+		if f.state != nil && deal.State != *f.state {
+			continue
+		}
+		if f.sector != nil && deal.SectorNumber != *f.sector {
+			continue
+		}
+
+		created := deal.CreationTime.Time()
+		if !f.since.IsZero() && created.Before(f.since) {
+			continue
+		}
+		if !f.until.IsZero() && !created.Before(f.until) {
+			continue
+		}
+
+		if f.client != address.Undef && !f.matchClient(ctx, deal.Proposal.Client) {
+			continue
+		}
+
+		out = append(out, deal)
+	}
+
+	switch f.sortBy {
+	case "price":
+		sort.SliceStable(out, func(i, j int) bool {
+			return out[i].Proposal.StoragePricePerEpoch.LessThan(out[j].Proposal.StoragePricePerEpoch)
+		})
+	default:
+		sort.SliceStable(out, func(i, j int) bool {
+			return out[i].CreationTime.Time().Before(out[j].CreationTime.Time())
+		})
+	}
+
+	end := len(out) - f.offset
+	if end < 0 {
+		end = 0
+	}
+	start := 0
+	if f.limit > 0 && end > f.limit {
+		start = end - f.limit
+	}
+
+	return out[start:end]
+}
+
+// matchClient compares client addresses by ID address. Addresses which can't
+// be resolved only match by their raw address, so a lookup failure skips the
+// deal instead of aborting the listing.
+func (f *storageDealsFilter) matchClient(ctx context.Context, client address.Address) bool {
+	if client == f.client {
+		return true
+	}
+
+	want, ok := f.clientID(ctx, f.client)
+	if !ok {
+		return false
+	}
+
+	got, ok := f.clientID(ctx, client)
+	return ok && want == got
+}
+
+// clientID returns the cached ID address of a, and false if it can't be
+// resolved
+func (f *storageDealsFilter) clientID(ctx context.Context, a address.Address) (address.Address, bool) {
+	if id, ok := f.clientIDs[a]; ok {
+		return id, id != address.Undef
+	}
+
+	id, err := dealfilter.ResolveClientAddress(ctx, f.resolver, a)
+	if err != nil {
+		log.Warnw("failed to resolve client address", "address", a, "error", err)
+		id = address.Undef
+	}
+
+	f.clientIDs[a] = id
+	return id, id != address.Undef
+}
+
+func outputStorageDeals(out io.Writer, deals []storagemarket.MinerDeal, verbose bool) error {
 	w := tabwriter.NewWriter(out, 2, 4, 2, ' ', 0)
 
 	if verbose {
-		_, _ = fmt.Fprintf(w, "Creation\tVerified\tProposalCid\tDealId\tState\tClient\tSize\tPrice\tDuration\tTransferChannelID\tMessage\n")
+		_, _ = fmt.Fprintf(w, "Creation\tVerified\tProposalCid\tDealId\tState\tClient\tSize\tPrice\tDuration\tTransferChannelID\tLabel\tMessage\n")
 	} else {
 		_, _ = fmt.Fprintf(w, "ProposalCid\tDealId\tState\tClient\tSize\tPrice\tDuration\n")
 	}
@@ -477,6 +714,7 @@ func outputStorageDeals(out io.Writer, deals []storagemarket.MinerDeal, verbose
 				tchid = deal.TransferChannelId.String()
 			}
 			_, _ = fmt.Fprintf(w, "\t%s", tchid)
+			_, _ = fmt.Fprintf(w, "\t%s", deal.Proposal.Label)
 			_, _ = fmt.Fprintf(w, "\t%s", deal.Message)
 		}
 
@@ -486,6 +724,128 @@ func outputStorageDeals(out io.Writer, deals []storagemarket.MinerDeal, verbose
 	return w.Flush()
 }
 
+var dealsStatsCmd = &cli.Command{
+	Name:  "stats",
+	Usage: "Print a summary of the deals tracked by this miner",
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		ctx := lcli.DaemonContext(cctx)
+
+		deals, err := api.MarketListIncompleteDeals(ctx)
+		if err != nil {
+			return err
+		}
+
+		return outputStorageDealStats(os.Stdout, summarizeStorageDeals(deals))
+	},
+}
+
+type dealStats struct {
+	Count int
+	Size  abi.PaddedPieceSize
+	Price abi.TokenAmount
+}
+
+func (s *dealStats) add(deal storagemarket.MinerDeal) {
+	s.Count++
+	s.Size += deal.Proposal.PieceSize
+	s.Price = types.BigAdd(s.Price, types.BigMul(deal.Proposal.StoragePricePerEpoch, big.NewInt(int64(deal.Proposal.Duration()))))
+}
+
+// failedDealStates are the states of deals which were rejected or failed, and
+// will never be stored
+var failedDealStates = map[storagemarket.StorageDealStatus]struct{}{
+	storagemarket.StorageDealRejecting: {},
+	storagemarket.StorageDealFailing:   {},
+	storagemarket.StorageDealError:     {},
+}
+
+type storageDealsSummary struct {
+	// deals which are stored or in progress, excluding failed deals
+	Total   dealStats
+	Failed  dealStats
+	ByState map[storagemarket.StorageDealStatus]*dealStats
+	// number of rejected or failed deals by their message
+	FailureReasons map[string]int
+}
+
+func summarizeStorageDeals(deals []storagemarket.MinerDeal) *storageDealsSummary {
+	sum := &storageDealsSummary{
+		Total:          dealStats{Price: big.Zero()},
+		Failed:         dealStats{Price: big.Zero()},
+		ByState:        map[storagemarket.StorageDealStatus]*dealStats{},
+		FailureReasons: map[string]int{},
+	}
+
+	for _, deal := range deals {
+		if _, failed := failedDealStates[deal.State]; failed {
+			sum.Failed.add(deal)
+			sum.FailureReasons[deal.Message]++
+		} else {
+			sum.Total.add(deal)
+		}
+
+		st, ok := sum.ByState[deal.State]
+		if !ok {
+			st = &dealStats{Price: big.Zero()}
+			sum.ByState[deal.State] = st
+		}
+		st.add(deal)
+	}
+
+	return sum
+}
+
+func outputStorageDealStats(out io.Writer, sum *storageDealsSummary) error {
+	_, _ = fmt.Fprintf(out, "Stored or in progress: %d deals, %s, %s\n", sum.Total.Count, units.BytesSize(float64(sum.Total.Size)), types.FIL(sum.Total.Price))
+	_, _ = fmt.Fprintf(out, "Rejected or failed: %d deals, %s, %s\n\n", sum.Failed.Count, units.BytesSize(float64(sum.Failed.Size)), types.FIL(sum.Failed.Price))
+
+	states := make([]storagemarket.StorageDealStatus, 0, len(sum.ByState))
+	for st := range sum.ByState {
+		states = append(states, st)
+	}
+	sort.Slice(states, func(i, j int) bool {
+		return states[i] < states[j]
+	})
+
+	w := tabwriter.NewWriter(out, 2, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintf(w, "State\tDeals\tSize\tPrice\n")
+	for _, st := range states {
+		s := sum.ByState[st]
+		_, _ = fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", storagemarket.DealStates[st], s.Count, units.BytesSize(float64(s.Size)), types.FIL(s.Price))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(sum.FailureReasons) == 0 {
+		return nil
+	}
+
+	reasons := make([]string, 0, len(sum.FailureReasons))
+	for r := range sum.FailureReasons {
+		reasons = append(reasons, r)
+	}
+	sort.Slice(reasons, func(i, j int) bool {
+		if sum.FailureReasons[reasons[i]] != sum.FailureReasons[reasons[j]] {
+			return sum.FailureReasons[reasons[i]] > sum.FailureReasons[reasons[j]]
+		}
+		return reasons[i] < reasons[j]
+	})
+
+	_, _ = fmt.Fprintf(out, "\nFailure reasons:\n")
+	w = tabwriter.NewWriter(out, 2, 4, 2, ' ', 0)
+	for _, r := range reasons {
+		_, _ = fmt.Fprintf(w, "%d\t%s\n", sum.FailureReasons[r], r)
+	}
+	return w.Flush()
+}
+
 var getBlocklistCmd = &cli.Command{
 	Name:  "get-blocklist",
 	Usage: "List the contents of the miner's piece CID blocklist",
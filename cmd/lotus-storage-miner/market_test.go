@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+	cbg "github.com/whyrusleeping/cbor-gen"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+func dealsListContext(t *testing.T, args ...string) *cli.Context {
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	for _, f := range dealsListCmd.Flags {
+		require.NoError(t, f.Apply(set))
+	}
+	require.NoError(t, set.Parse(args))
+
+	return cli.NewContext(cli.NewApp(), set, nil)
+}
+
+func TestNewStorageDealsFilter(t *testing.T) {
+	active := storagemarket.StorageDealActive
+	sealing := storagemarket.StorageDealSealing
+
+	tcs := map[string]struct {
+		args  []string
+		state *storagemarket.StorageDealStatus
+		err   bool
+	}{
+		"no flags":         {},
+		"full state name":  {args: []string{"--state", "StorageDealActive"}, state: &active},
+		"short state name": {args: []string{"--state", "Active"}, state: &active},
+		"case insensitive": {args: []string{"--state", "sealing"}, state: &sealing},
+		"unknown state":    {args: []string{"--state", "NotAState"}, err: true},
+		"negative limit":   {args: []string{"--limit", "-1"}, err: true},
+		"negative offset":  {args: []string{"--offset", "-1"}, err: true},
+		"unknown sort":     {args: []string{"--sort", "size"}, err: true},
+		"bad client":       {args: []string{"--client", "notanaddress"}, err: true},
+		"bad since":        {args: []string{"--since", "yesterday"}, err: true},
+		"since after until": {
+			args: []string{"--since", "2021-06-02T00:00:00Z", "--until", "2021-06-01T00:00:00Z"},
+			err:  true,
+		},
+	}
+
+	for name, tc := range tcs {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			f, err := newStorageDealsFilter(dealsListContext(t, tc.args...))
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.state, f.state)
+		})
+	}
+}
+
+func TestStorageDealsFilterApply(t *testing.T) {
+	ctx := context.Background()
+	base := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	// deal i is created i hours after base, with a price that decreases with age
+	var deals []storagemarket.MinerDeal
+	for i := 0; i < 5; i++ {
+		var d storagemarket.MinerDeal
+		d.CreationTime = cbg.CborTime(base.Add(time.Duration(i) * time.Hour))
+		d.Proposal.StoragePricePerEpoch = abi.NewTokenAmount(int64(10 - i))
+		d.SectorNumber = abi.SectorNumber(i % 2)
+		deals = append(deals, d)
+	}
+
+	// deliberately unordered input
+	input := []storagemarket.MinerDeal{deals[3], deals[0], deals[4], deals[1], deals[2]}
+
+	tcs := map[string]struct {
+		args   []string
+		expect []storagemarket.MinerDeal
+	}{
+		"all, oldest first":     {expect: deals},
+		"limit keeps newest":    {args: []string{"--limit", "2"}, expect: deals[3:]},
+		"offset skips newest":   {args: []string{"--offset", "1", "--limit", "2"}, expect: deals[2:4]},
+		"offset past all deals": {args: []string{"--offset", "10"}, expect: []storagemarket.MinerDeal{}},
+		"sector":                {args: []string{"--sector", "1"}, expect: []storagemarket.MinerDeal{deals[1], deals[3]}},
+		"time range": {
+			args:   []string{"--since", "2021-06-01T01:00:00Z", "--until", "2021-06-01T03:00:00Z"},
+			expect: deals[1:3],
+		},
+		"sort by price": {
+			args:   []string{"--sort", "price", "--limit", "2"},
+			expect: []storagemarket.MinerDeal{deals[1], deals[0]},
+		},
+	}
+
+	for name, tc := range tcs {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			f, err := newStorageDealsFilter(dealsListContext(t, tc.args...))
+			require.NoError(t, err)
+
+			require.Equal(t, tc.expect, f.apply(ctx, input))
+		})
+	}
+}
+
+type mockResolver map[address.Address]address.Address
+
+func (m mockResolver) StateLookupID(ctx context.Context, a address.Address, tsk types.TipSetKey) (address.Address, error) {
+	id, ok := m[a]
+	if !ok {
+		return address.Undef, xerrors.Errorf("actor not found")
+	}
+	return id, nil
+}
+
+func TestStorageDealsFilterApplyClient(t *testing.T) {
+	ctx := context.Background()
+
+	mustAddr := func(s string) address.Address {
+		a, err := address.NewFromString(s)
+		require.NoError(t, err)
+		return a
+	}
+
+	aliceID := mustAddr("t01000")
+	alice := mustAddr("t1nvfp3sqenc3cqr5blwm3ztnofw5kf724zplvpea")
+	bob := mustAddr("t1m2jd3aq2udcltclf5z3bl2dqutykqde7swxgjda")
+	unknown := mustAddr("t1xphvks3kzfonmkg3ugllq2ztz53fti7uevld5eq")
+
+	resolver := mockResolver{
+		alice: aliceID,
+		bob:   mustAddr("t01001"),
+	}
+
+	base := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	var deals []storagemarket.MinerDeal
+	for i, client := range []address.Address{alice, aliceID, bob, unknown} {
+		var d storagemarket.MinerDeal
+		d.CreationTime = cbg.CborTime(base.Add(time.Duration(i) * time.Hour))
+		d.Proposal.Client = client
+		deals = append(deals, d)
+	}
+
+	tcs := map[string]struct {
+		client string
+		expect []storagemarket.MinerDeal
+	}{
+		"by ID address":      {client: "t01000", expect: deals[:2]},
+		"by robust address":  {client: alice.String(), expect: deals[:2]},
+		"other client":       {client: bob.String(), expect: deals[2:3]},
+		"unresolvable":       {client: unknown.String(), expect: deals[3:]},
+		"other client by ID": {client: "t01001", expect: deals[2:3]},
+	}
+
+	for name, tc := range tcs {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			f, err := newStorageDealsFilter(dealsListContext(t, "--client", tc.client))
+			require.NoError(t, err)
+			f.resolver = resolver
+
+			// the deal from the unresolvable client is skipped, not an error
+			require.Equal(t, tc.expect, f.apply(ctx, deals))
+		})
+	}
+}
+
+func TestSummarizeStorageDeals(t *testing.T) {
+	mkDeal := func(state storagemarket.StorageDealStatus, size abi.PaddedPieceSize, price int64, msg string) storagemarket.MinerDeal {
+		var d storagemarket.MinerDeal
+		d.State = state
+		d.Message = msg
+		d.Proposal.PieceSize = size
+		d.Proposal.StoragePricePerEpoch = abi.NewTokenAmount(price)
+		d.Proposal.StartEpoch = 100
+		d.Proposal.EndEpoch = 110
+		return d
+	}
+
+	sum := summarizeStorageDeals([]storagemarket.MinerDeal{
+		mkDeal(storagemarket.StorageDealActive, 1024, 1, ""),
+		mkDeal(storagemarket.StorageDealActive, 2048, 2, ""),
+		mkDeal(storagemarket.StorageDealSealing, 512, 3, ""),
+		mkDeal(storagemarket.StorageDealError, 256, 4, "deal rejected: miner has blocklisted piece CID"),
+		mkDeal(storagemarket.StorageDealError, 256, 4, "deal rejected: miner has blocklisted piece CID"),
+	})
+
+	// failed deals are counted separately from the total
+	require.Equal(t, 3, sum.Total.Count)
+	require.Equal(t, abi.PaddedPieceSize(3584), sum.Total.Size)
+	require.Equal(t, abi.NewTokenAmount(60), sum.Total.Price)
+
+	require.Equal(t, 2, sum.Failed.Count)
+	require.Equal(t, abi.PaddedPieceSize(512), sum.Failed.Size)
+	require.Equal(t, abi.NewTokenAmount(80), sum.Failed.Price)
+
+	require.Len(t, sum.ByState, 3)
+	active := sum.ByState[storagemarket.StorageDealActive]
+	require.Equal(t, 2, active.Count)
+	require.Equal(t, abi.PaddedPieceSize(3072), active.Size)
+	require.Equal(t, abi.NewTokenAmount(30), active.Price)
+
+	require.Equal(t, map[string]int{"deal rejected: miner has blocklisted piece CID": 2}, sum.FailureReasons)
+}
+
+func TestDealStatsNegativeDuration(t *testing.T) {
+	var d storagemarket.MinerDeal
+	d.Proposal.StoragePricePerEpoch = abi.NewTokenAmount(3)
+	d.Proposal.StartEpoch = 110
+	d.Proposal.EndEpoch = 100
+
+	// a malformed proposal must not wrap around to a huge price
+	s := dealStats{Price: big.Zero()}
+	s.add(d)
+	require.Equal(t, abi.NewTokenAmount(-30), s.Price)
+}
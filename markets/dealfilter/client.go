@@ -0,0 +1,120 @@
+package dealfilter
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	logging "github.com/ipfs/go-log/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+var log = logging.Logger("dealfilter")
+
+// lookupRetryInterval is how long a failed ID address lookup is remembered
+// before the address is looked up again
+const lookupRetryInterval = 10 * time.Minute
+
+// AddressResolver resolves addresses to their ID addresses from chain state.
+type AddressResolver interface {
+	StateLookupID(context.Context, address.Address, types.TipSetKey) (address.Address, error)
+}
+
+// ResolveClientAddress returns the ID address of a deal client. A deal
+// proposal may name its client by ID or by robust (f1/f3) address, so
+// addresses must be resolved before they can be compared.
+func ResolveClientAddress(ctx context.Context, r AddressResolver, a address.Address) (address.Address, error) {
+	if a.Protocol() == address.ID {
+		return a, nil
+	}
+
+	id, err := r.StateLookupID(ctx, a, types.EmptyTSK)
+	if err != nil {
+		return address.Undef, xerrors.Errorf("looking up ID address of %s: %w", a, err)
+	}
+
+	return id, nil
+}
+
+// ClientAddressMatcher checks deal clients against the client blocklist and
+// allowlist, comparing addresses by ID address. Lookups are cached, so the
+// lists are not resolved again for every proposal.
+type ClientAddressMatcher struct {
+	r AddressResolver
+
+	lk     sync.Mutex
+	ids    map[address.Address]address.Address
+	failed map[address.Address]time.Time
+}
+
+func NewClientAddressMatcher(r AddressResolver) *ClientAddressMatcher {
+	return &ClientAddressMatcher{
+		r:      r,
+		ids:    map[address.Address]address.Address{},
+		failed: map[address.Address]time.Time{},
+	}
+}
+
+// resolve returns the ID address of a, or a itself when it can't be looked up
+// (e.g. an address which doesn't have an actor on chain yet), in which case
+// it can still be compared by its raw address.
+func (m *ClientAddressMatcher) resolve(ctx context.Context, a address.Address) address.Address {
+	if a.Protocol() == address.ID {
+		return a
+	}
+
+	m.lk.Lock()
+	defer m.lk.Unlock()
+
+	if id, ok := m.ids[a]; ok {
+		return id
+	}
+	if at, ok := m.failed[a]; ok && time.Since(at) < lookupRetryInterval {
+		return a
+	}
+
+	id, err := ResolveClientAddress(ctx, m.r, a)
+	if err != nil {
+		log.Warnw("failed to resolve client address, comparing raw address", "address", a, "error", err)
+		m.failed[a] = time.Now()
+		return a
+	}
+
+	delete(m.failed, a)
+	m.ids[a] = id
+	return id
+}
+
+// Check decides whether a storage deal from the given client is acceptable
+// under the client blocklist and allowlist. An empty allowlist allows all
+// clients that are not blocklisted.
+func (m *ClientAddressMatcher) Check(ctx context.Context, client address.Address, blocklist, allowlist []address.Address) (bool, string) {
+	if len(blocklist) == 0 && len(allowlist) == 0 {
+		return true, ""
+	}
+
+	clientID := m.resolve(ctx, client)
+
+	for _, a := range blocklist {
+		if a == client || m.resolve(ctx, a) == clientID {
+			return false, fmt.Sprintf("miner has blocklisted client address %s", client)
+		}
+	}
+
+	if len(allowlist) == 0 {
+		return true, ""
+	}
+
+	for _, a := range allowlist {
+		if a == client || m.resolve(ctx, a) == clientID {
+			return true, ""
+		}
+	}
+
+	return false, fmt.Sprintf("miner is not accepting storage deals from client address %s", client)
+}
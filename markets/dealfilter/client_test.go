@@ -0,0 +1,165 @@
+package dealfilter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+type mockResolver struct {
+	ids     map[address.Address]address.Address
+	lookups map[address.Address]int
+}
+
+func newMockResolver(ids map[address.Address]address.Address) *mockResolver {
+	return &mockResolver{ids: ids, lookups: map[address.Address]int{}}
+}
+
+func (m *mockResolver) StateLookupID(ctx context.Context, a address.Address, tsk types.TipSetKey) (address.Address, error) {
+	m.lookups[a]++
+	id, ok := m.ids[a]
+	if !ok {
+		return address.Undef, xerrors.Errorf("actor not found")
+	}
+	return id, nil
+}
+
+func TestClientAddressMatcher(t *testing.T) {
+	ctx := context.Background()
+
+	mustAddr := func(s string) address.Address {
+		a, err := address.NewFromString(s)
+		require.NoError(t, err)
+		return a
+	}
+
+	aliceID := mustAddr("t01000")
+	alice := mustAddr("t1nvfp3sqenc3cqr5blwm3ztnofw5kf724zplvpea")
+	bobID := mustAddr("t01001")
+	bob := mustAddr("t1m2jd3aq2udcltclf5z3bl2dqutykqde7swxgjda")
+	unknown := mustAddr("t1xphvks3kzfonmkg3ugllq2ztz53fti7uevld5eq")
+
+	ids := map[address.Address]address.Address{
+		alice: aliceID,
+		bob:   bobID,
+	}
+
+	tcs := map[string]struct {
+		client    address.Address
+		blocklist []address.Address
+		allowlist []address.Address
+
+		accept bool
+		reason string
+	}{
+		"no lists": {
+			client: unknown,
+			accept: true,
+		},
+		"blocklist hit": {
+			client:    alice,
+			blocklist: []address.Address{alice},
+			reason:    "miner has blocklisted client address " + alice.String(),
+		},
+		"blocklist miss": {
+			client:    bob,
+			blocklist: []address.Address{alice},
+			accept:    true,
+		},
+		"blocklisted robust, proposed by ID": {
+			client:    aliceID,
+			blocklist: []address.Address{alice},
+			reason:    "miner has blocklisted client address " + aliceID.String(),
+		},
+		"blocklisted ID, proposed by robust": {
+			client:    alice,
+			blocklist: []address.Address{aliceID},
+			reason:    "miner has blocklisted client address " + alice.String(),
+		},
+		"allowlist hit": {
+			client:    bob,
+			allowlist: []address.Address{bob},
+			accept:    true,
+		},
+		"allowlist miss": {
+			client:    bob,
+			allowlist: []address.Address{alice},
+			reason:    "miner is not accepting storage deals from client address " + bob.String(),
+		},
+		"allowlisted robust, proposed by ID": {
+			client:    bobID,
+			allowlist: []address.Address{bob},
+			accept:    true,
+		},
+		"empty allowlist": {
+			client:    bob,
+			blocklist: []address.Address{alice},
+			allowlist: []address.Address{},
+			accept:    true,
+		},
+		"blocklist wins over allowlist": {
+			client:    alice,
+			blocklist: []address.Address{aliceID},
+			allowlist: []address.Address{alice},
+			reason:    "miner has blocklisted client address " + alice.String(),
+		},
+		"unresolvable client, not listed": {
+			client:    unknown,
+			blocklist: []address.Address{alice},
+			accept:    true,
+		},
+		"unresolvable client, blocklisted": {
+			client:    unknown,
+			blocklist: []address.Address{unknown},
+			reason:    "miner has blocklisted client address " + unknown.String(),
+		},
+		"unresolvable allowlist entry, other client": {
+			client:    bob,
+			allowlist: []address.Address{unknown},
+			reason:    "miner is not accepting storage deals from client address " + bob.String(),
+		},
+		"unresolvable allowlist entry, same client": {
+			client:    unknown,
+			allowlist: []address.Address{bob, unknown},
+			accept:    true,
+		},
+	}
+
+	for name, tc := range tcs {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			m := NewClientAddressMatcher(newMockResolver(ids))
+			accept, reason := m.Check(ctx, tc.client, tc.blocklist, tc.allowlist)
+			require.Equal(t, tc.accept, accept)
+			require.Equal(t, tc.reason, reason)
+		})
+	}
+}
+
+func TestClientAddressMatcherCachesLookups(t *testing.T) {
+	ctx := context.Background()
+
+	alice, err := address.NewFromString("t1nvfp3sqenc3cqr5blwm3ztnofw5kf724zplvpea")
+	require.NoError(t, err)
+	aliceID, err := address.NewFromString("t01000")
+	require.NoError(t, err)
+	unknown, err := address.NewFromString("t1xphvks3kzfonmkg3ugllq2ztz53fti7uevld5eq")
+	require.NoError(t, err)
+
+	r := newMockResolver(map[address.Address]address.Address{alice: aliceID})
+	m := NewClientAddressMatcher(r)
+
+	for i := 0; i < 3; i++ {
+		accept, _ := m.Check(ctx, aliceID, []address.Address{unknown, alice}, nil)
+		require.False(t, accept)
+	}
+
+	// each list entry is looked up once, failed lookups included
+	require.Equal(t, map[address.Address]int{alice: 1, unknown: 1}, r.lookups)
+}
@@ -0,0 +1,328 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"go.uber.org/fx"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	"github.com/filecoin-project/go-state-types/abi"
+)
+
+var log = logging.Logger("webhooks")
+
+// SignatureHeader is the HTTP header carrying the hex encoded HMAC-SHA256
+// signature of the request body, when a secret is configured.
+const SignatureHeader = "X-Lotus-Signature"
+
+const (
+	defaultMaxAttempts = 5
+	defaultRetryDelay  = time.Second
+	requestTimeout     = 30 * time.Second
+	queueSize          = 256
+)
+
+// dealEvents maps the deal states which trigger a notification to the event
+// name sent to the webhook endpoints. A deal enters StorageDealFinalizing once
+// its sector has been sealed.
+var dealEvents = map[storagemarket.StorageDealStatus]string{
+	storagemarket.StorageDealWaitingForData: "accepted",
+	storagemarket.StorageDealFinalizing:     "sealed",
+	storagemarket.StorageDealActive:         "active",
+	storagemarket.StorageDealExpired:        "expired",
+	storagemarket.StorageDealSlashed:        "slashed",
+	storagemarket.StorageDealError:          "failed",
+}
+
+type Config struct {
+	// HTTP endpoints to POST deal events to
+	Endpoints []string
+	// Secret used to sign payloads, signing is disabled when empty
+	Secret string
+	// Maximum number of delivery attempts per endpoint and event
+	MaxAttempts int
+	// Delay before the first retry, doubled after each failed attempt
+	RetryDelay time.Duration
+}
+
+// DealEvent is the JSON payload POSTed to webhook endpoints
+type DealEvent struct {
+	Event string
+	Time  time.Time
+
+	ProposalCid   cid.Cid
+	DealID        abi.DealID
+	State         string
+	Message       string
+	Client        address.Address
+	ClientPeer    peer.ID
+	PieceCID      cid.Cid
+	PieceSize     abi.PaddedPieceSize
+	StartEpoch    abi.ChainEpoch
+	EndEpoch      abi.ChainEpoch
+	PricePerEpoch abi.TokenAmount
+	VerifiedDeal  bool
+	SectorNumber  abi.SectorNumber
+}
+
+// Notifier POSTs a JSON summary of a storage deal to the configured webhook
+// endpoints when the deal reaches one of the key states (accepted, sealed,
+// active, expired, slashed, failed). Failures are only reported for deals
+// which were reported as accepted, so rejected proposals don't generate events.
+// Each endpoint has its own queue, delivered in the background with retries,
+// so a slow or failing endpoint never holds up deal processing or delivery to
+// the other endpoints. Delivery is at least once: after a restart, deals may
+// be reported in their current state again.
+type Notifier struct {
+	cfg    Config
+	client *http.Client
+
+	ctx       context.Context
+	shutdown  context.CancelFunc
+	endpoints []*endpoint
+	wg        sync.WaitGroup
+
+	lk       sync.Mutex
+	notified map[cid.Cid]storagemarket.StorageDealStatus
+}
+
+// endpoint is the delivery queue of a single webhook endpoint
+type endpoint struct {
+	url   string
+	queue chan DealEvent
+}
+
+func NewNotifier(cfg Config) func(lc fx.Lifecycle) (*Notifier, error) {
+	return func(lc fx.Lifecycle) (*Notifier, error) {
+		n, err := newNotifier(cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		lc.Append(fx.Hook{
+			OnStart: func(context.Context) error {
+				n.Start()
+				return nil
+			},
+			OnStop: n.Stop,
+		})
+		return n, nil
+	}
+}
+
+func newNotifier(cfg Config) (*Notifier, error) {
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = defaultMaxAttempts
+	}
+	if cfg.RetryDelay <= 0 {
+		cfg.RetryDelay = defaultRetryDelay
+	}
+
+	endpoints := make([]*endpoint, 0, len(cfg.Endpoints))
+	for _, e := range cfg.Endpoints {
+		u, err := url.Parse(e)
+		if err != nil {
+			return nil, xerrors.Errorf("parsing webhook endpoint %q: %w", e, err)
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, xerrors.Errorf("webhook endpoint %q must be an absolute http(s) URL", e)
+		}
+
+		endpoints = append(endpoints, &endpoint{
+			url:   u.String(),
+			queue: make(chan DealEvent, queueSize),
+		})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Notifier{
+		cfg:       cfg,
+		client:    &http.Client{Timeout: requestTimeout},
+		ctx:       ctx,
+		shutdown:  cancel,
+		endpoints: endpoints,
+		notified:  map[cid.Cid]storagemarket.StorageDealStatus{},
+	}, nil
+}
+
+// Start starts delivering queued events
+func (n *Notifier) Start() {
+	for _, e := range n.endpoints {
+		n.wg.Add(1)
+		go n.run(e)
+	}
+}
+
+// Stop stops delivery, dropping any events which haven't been delivered yet
+func (n *Notifier) Stop(ctx context.Context) error {
+	n.shutdown()
+
+	done := make(chan struct{})
+	go func() {
+		n.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// OnDealEvent is a storage provider event subscriber which queues a
+// notification whenever a deal enters one of the key states.
+func (n *Notifier) OnDealEvent(_ storagemarket.ProviderEvent, deal storagemarket.MinerDeal) {
+	if len(n.endpoints) == 0 {
+		return
+	}
+
+	name, ok := dealEvents[deal.State]
+	if !ok {
+		return
+	}
+
+	n.lk.Lock()
+	last, reported := n.notified[deal.ProposalCid]
+	if reported && last == deal.State {
+		n.lk.Unlock()
+		return
+	}
+	if deal.State == storagemarket.StorageDealError && !reported {
+		// the deal was never accepted, e.g. a proposal rejected by the deal filter
+		n.lk.Unlock()
+		return
+	}
+	switch deal.State {
+	case storagemarket.StorageDealExpired, storagemarket.StorageDealSlashed, storagemarket.StorageDealError:
+		// no more events are expected for the deal
+		delete(n.notified, deal.ProposalCid)
+	default:
+		n.notified[deal.ProposalCid] = deal.State
+	}
+	n.lk.Unlock()
+
+	evt := DealEvent{
+		Event:         name,
+		Time:          time.Now(),
+		ProposalCid:   deal.ProposalCid,
+		DealID:        deal.DealID,
+		State:         storagemarket.DealStates[deal.State],
+		Message:       deal.Message,
+		Client:        deal.Proposal.Client,
+		ClientPeer:    deal.Client,
+		PieceCID:      deal.Proposal.PieceCID,
+		PieceSize:     deal.Proposal.PieceSize,
+		StartEpoch:    deal.Proposal.StartEpoch,
+		EndEpoch:      deal.Proposal.EndEpoch,
+		PricePerEpoch: deal.Proposal.StoragePricePerEpoch,
+		VerifiedDeal:  deal.Proposal.VerifiedDeal,
+		SectorNumber:  deal.SectorNumber,
+	}
+
+	for _, e := range n.endpoints {
+		select {
+		case e.queue <- evt:
+		default:
+			log.Warnw("webhook queue full, dropping deal event", "endpoint", e.url, "event", name, "proposal", deal.ProposalCid)
+		}
+	}
+}
+
+func (n *Notifier) run(e *endpoint) {
+	defer n.wg.Done()
+
+	for {
+		select {
+		case <-n.ctx.Done():
+			return
+		case evt := <-e.queue:
+			n.deliver(e, evt)
+		}
+	}
+}
+
+func (n *Notifier) deliver(e *endpoint, evt DealEvent) {
+	body, err := json.Marshal(evt)
+	if err != nil {
+		log.Errorw("marshaling webhook deal event", "event", evt.Event, "proposal", evt.ProposalCid, "error", err)
+		return
+	}
+
+	if err := n.postWithRetry(e.url, body); err != nil {
+		log.Warnw("failed to deliver webhook deal event", "endpoint", e.url, "event", evt.Event, "proposal", evt.ProposalCid, "error", err)
+	}
+}
+
+func (n *Notifier) postWithRetry(endpoint string, body []byte) error {
+	delay := n.cfg.RetryDelay
+
+	var err error
+	for attempt := 1; attempt <= n.cfg.MaxAttempts; attempt++ {
+		if err = n.post(endpoint, body); err == nil {
+			return nil
+		}
+
+		if attempt == n.cfg.MaxAttempts {
+			break
+		}
+
+		log.Debugw("webhook delivery failed, retrying", "endpoint", endpoint, "attempt", attempt, "error", err)
+
+		select {
+		case <-time.After(delay):
+		case <-n.ctx.Done():
+			return n.ctx.Err()
+		}
+		delay *= 2
+	}
+
+	return xerrors.Errorf("giving up after %d attempts: %w", n.cfg.MaxAttempts, err)
+}
+
+func (n *Notifier) post(endpoint string, body []byte) error {
+	req, err := http.NewRequestWithContext(n.ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return xerrors.Errorf("creating request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if n.cfg.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign([]byte(n.cfg.Secret), body))
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return xerrors.Errorf("unexpected response status: %s", resp.Status)
+	}
+
+	return nil
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of body, as sent in the
+// SignatureHeader.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	_, _ = mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,224 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+// received holds the parts of a delivered DealEvent checked by the test
+type received struct {
+	evt struct {
+		Event       string
+		ProposalCid cid.Cid
+		PieceSize   abi.PaddedPieceSize
+	}
+	signature string
+	body      []byte
+}
+
+func TestNotifier(t *testing.T) {
+	var lk sync.Mutex
+	var got []received
+	failures := 2
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lk.Lock()
+		defer lk.Unlock()
+
+		// fail the first requests to exercise retries
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		rcv := received{signature: r.Header.Get(SignatureHeader), body: body}
+		require.NoError(t, json.Unmarshal(body, &rcv.evt))
+		got = append(got, rcv)
+	}))
+	defer srv.Close()
+
+	secret := []byte("secret")
+	n, err := newNotifier(Config{
+		Endpoints:   []string{srv.URL},
+		Secret:      string(secret),
+		MaxAttempts: 3,
+		RetryDelay:  time.Millisecond,
+	})
+	require.NoError(t, err)
+	n.Start()
+	defer n.Stop(context.Background()) //nolint:errcheck
+
+	propCid, err := abi.CidBuilder.Sum([]byte("proposal"))
+	require.NoError(t, err)
+
+	var deal storagemarket.MinerDeal
+	deal.ProposalCid = propCid
+	deal.Proposal.PieceSize = 2048
+	deal.Proposal.StoragePricePerEpoch = types.NewInt(10)
+
+	for _, st := range []storagemarket.StorageDealStatus{
+		storagemarket.StorageDealValidating,     // not a key state
+		storagemarket.StorageDealWaitingForData, // accepted
+		storagemarket.StorageDealWaitingForData, // duplicate, not re-sent
+		storagemarket.StorageDealTransferring,   // not a key state
+		storagemarket.StorageDealSealing,        // not a key state
+		storagemarket.StorageDealFinalizing,     // sealed
+		storagemarket.StorageDealActive,         // active
+	} {
+		deal.State = st
+		n.OnDealEvent(storagemarket.ProviderEventOpen, deal)
+	}
+
+	require.Eventually(t, func() bool {
+		lk.Lock()
+		defer lk.Unlock()
+		return len(got) == 3
+	}, 5*time.Second, 10*time.Millisecond)
+
+	lk.Lock()
+	defer lk.Unlock()
+
+	var names []string
+	for _, r := range got {
+		names = append(names, r.evt.Event)
+		require.Equal(t, deal.ProposalCid, r.evt.ProposalCid)
+		require.Equal(t, abi.PaddedPieceSize(2048), r.evt.PieceSize)
+		require.Equal(t, Sign(secret, r.body), r.signature)
+	}
+	require.Equal(t, []string{"accepted", "sealed", "active"}, names)
+}
+
+func TestNotifierFailedOnlyAfterAccepted(t *testing.T) {
+	var lk sync.Mutex
+	var got []string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var evt struct{ Event string }
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&evt))
+
+		lk.Lock()
+		defer lk.Unlock()
+		got = append(got, evt.Event)
+	}))
+	defer srv.Close()
+
+	n, err := newNotifier(Config{Endpoints: []string{srv.URL}})
+	require.NoError(t, err)
+	n.Start()
+	defer n.Stop(context.Background()) //nolint:errcheck
+
+	rejected, err := abi.CidBuilder.Sum([]byte("rejected"))
+	require.NoError(t, err)
+	accepted, err := abi.CidBuilder.Sum([]byte("accepted"))
+	require.NoError(t, err)
+
+	// a proposal rejected by the deal filter goes straight to the error state
+	n.OnDealEvent(storagemarket.ProviderEventOpen, storagemarket.MinerDeal{ProposalCid: rejected, State: storagemarket.StorageDealError})
+
+	n.OnDealEvent(storagemarket.ProviderEventOpen, storagemarket.MinerDeal{ProposalCid: accepted, State: storagemarket.StorageDealWaitingForData})
+	n.OnDealEvent(storagemarket.ProviderEventOpen, storagemarket.MinerDeal{ProposalCid: accepted, State: storagemarket.StorageDealError})
+
+	require.Eventually(t, func() bool {
+		lk.Lock()
+		defer lk.Unlock()
+		return len(got) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	lk.Lock()
+	defer lk.Unlock()
+	require.Equal(t, []string{"accepted", "failed"}, got)
+}
+
+func TestNotifierEndpointsAreIndependent(t *testing.T) {
+	release := make(chan struct{})
+	dead := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// hang until the test is done
+		<-release
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer dead.Close()
+	defer close(release)
+
+	var lk sync.Mutex
+	delivered := 0
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lk.Lock()
+		defer lk.Unlock()
+		delivered++
+	}))
+	defer healthy.Close()
+
+	n, err := newNotifier(Config{Endpoints: []string{dead.URL, healthy.URL}})
+	require.NoError(t, err)
+	n.Start()
+	defer n.Stop(context.Background()) //nolint:errcheck
+
+	const deals = 10
+	for i := 0; i < deals; i++ {
+		propCid, err := abi.CidBuilder.Sum([]byte{byte(i)})
+		require.NoError(t, err)
+		n.OnDealEvent(storagemarket.ProviderEventOpen, storagemarket.MinerDeal{ProposalCid: propCid, State: storagemarket.StorageDealWaitingForData})
+	}
+
+	require.Eventually(t, func() bool {
+		lk.Lock()
+		defer lk.Unlock()
+		return delivered == deals
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestNewNotifierValidatesEndpoints(t *testing.T) {
+	for _, e := range []string{"", "localhost:8080", "ftp://example.com/hook", "http://", "http://[::1"} {
+		_, err := newNotifier(Config{Endpoints: []string{e}})
+		require.Error(t, err, e)
+	}
+
+	_, err := newNotifier(Config{Endpoints: []string{"http://localhost:8080/hook", "https://example.com"}})
+	require.NoError(t, err)
+}
+
+func TestNotifierGivesUp(t *testing.T) {
+	var lk sync.Mutex
+	attempts := 0
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lk.Lock()
+		defer lk.Unlock()
+
+		attempts++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	n, err := newNotifier(Config{
+		Endpoints:   []string{srv.URL},
+		MaxAttempts: 3,
+		RetryDelay:  time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	err = n.postWithRetry(srv.URL, []byte("{}"))
+	require.Error(t, err)
+
+	lk.Lock()
+	defer lk.Unlock()
+	require.Equal(t, 3, attempts)
+}
@@ -64,6 +64,7 @@ import (
 	_ "github.com/filecoin-project/lotus/lib/sigs/secp"
 	"github.com/filecoin-project/lotus/markets/dealfilter"
 	"github.com/filecoin-project/lotus/markets/storageadapter"
+	"github.com/filecoin-project/lotus/markets/webhooks"
 	"github.com/filecoin-project/lotus/miner"
 	"github.com/filecoin-project/lotus/node/config"
 	"github.com/filecoin-project/lotus/node/impl"
@@ -424,11 +425,13 @@ var MinerNode = Options(
 	// Markets (storage)
 	Override(new(dtypes.ProviderDataTransfer), modules.NewProviderDAGServiceDataTransfer),
 	Override(new(*storedask.StoredAsk), modules.NewStorageAsk),
+	Override(new(*dtypes.ActiveStorageDeals), dtypes.NewActiveStorageDeals),
 	Override(new(dtypes.StorageDealFilter), modules.BasicDealFilter(nil)),
 	Override(new(storagemarket.StorageProvider), modules.StorageProvider),
 	Override(new(*storageadapter.DealPublisher), storageadapter.NewDealPublisher(nil, storageadapter.PublishMsgConfig{})),
 	Override(new(storagemarket.StorageProviderNode), storageadapter.NewProviderNodeAdapter(nil, nil)),
 	Override(HandleMigrateProviderFundsKey, modules.HandleMigrateProviderFunds),
+	Override(new(*webhooks.Notifier), webhooks.NewNotifier(webhooks.Config{})),
 	Override(HandleDealsKey, modules.HandleDeals),
 
 	// Config (todo: get a real property system)
@@ -438,6 +441,8 @@ var MinerNode = Options(
 	Override(new(dtypes.SetConsiderOnlineRetrievalDealsConfigFunc), modules.NewSetConsiderOnlineRetrievalDealsConfigFunc),
 	Override(new(dtypes.StorageDealPieceCidBlocklistConfigFunc), modules.NewStorageDealPieceCidBlocklistConfigFunc),
 	Override(new(dtypes.SetStorageDealPieceCidBlocklistConfigFunc), modules.NewSetStorageDealPieceCidBlocklistConfigFunc),
+	Override(new(dtypes.StorageDealClientBlocklistConfigFunc), modules.NewStorageDealClientBlocklistConfigFunc),
+	Override(new(dtypes.StorageDealClientAllowlistConfigFunc), modules.NewStorageDealClientAllowlistConfigFunc),
 	Override(new(dtypes.ConsiderOfflineStorageDealsConfigFunc), modules.NewConsiderOfflineStorageDealsConfigFunc),
 	Override(new(dtypes.SetConsiderOfflineStorageDealsConfigFunc), modules.NewSetConsideringOfflineStorageDealsFunc),
 	Override(new(dtypes.ConsiderOfflineRetrievalDealsConfigFunc), modules.NewConsiderOfflineRetrievalDealsConfigFunc),
@@ -452,6 +457,8 @@ var MinerNode = Options(
 	Override(new(dtypes.GetExpectedSealDurationFunc), modules.NewGetExpectedSealDurationFunc),
 	Override(new(dtypes.SetMaxDealStartDelayFunc), modules.NewSetMaxDealStartDelayFunc),
 	Override(new(dtypes.GetMaxDealStartDelayFunc), modules.NewGetMaxDealStartDelayFunc),
+	Override(new(dtypes.GetMaxActiveDealsFunc), modules.NewGetMaxActiveDealsFunc),
+	Override(new(dtypes.GetDealGasConfigFunc), modules.NewGetDealGasConfigFunc),
 )
 
 // Online sets up basic libp2p node
@@ -606,6 +613,11 @@ func ConfigStorageMiner(c interface{}) Option {
 		})),
 		Override(new(storagemarket.StorageProviderNode), storageadapter.NewProviderNodeAdapter(&cfg.Fees, &cfg.Dealmaking)),
 
+		Override(new(*webhooks.Notifier), webhooks.NewNotifier(webhooks.Config{
+			Endpoints: cfg.Dealmaking.Webhooks,
+			Secret:    cfg.Dealmaking.WebhookSecret,
+		})),
+
 		Override(new(dtypes.StagingGraphsync), modules.StagingGraphsync(cfg.Dealmaking.SimultaneousTransfers)),
 
 		Override(new(sectorstorage.SealerConfig), cfg.Storage),
@@ -65,7 +65,11 @@ type DealmakingConfig struct {
 	ConsiderVerifiedStorageDeals   bool
 	ConsiderUnverifiedStorageDeals bool
 	PieceCidBlocklist              []cid.Cid
-	ExpectedSealDuration           Duration
+	// Client addresses from which the miner will not accept storage deals
+	ClientAddressBlocklist []string
+	// If not empty, only storage deals from these client addresses are accepted
+	ClientAddressAllowlist []string
+	ExpectedSealDuration   Duration
 	// Maximum amount of time proposed deal StartEpoch can be in future
 	MaxDealStartDelay Duration
 	// The amount of time to wait for more deals to arrive before
@@ -81,6 +85,28 @@ type DealmakingConfig struct {
 	// The maximum number of parallel online data transfers (storage+retrieval)
 	SimultaneousTransfers uint64
 
+	// The maximum number of storage deals being processed at once, from
+	// proposal until the deal is active on chain; new proposals above this
+	// limit are rejected. 0 = no limit
+	MaxActiveDeals uint64
+	// The gas expected to be used committing a storage deal into a sector.
+	// When not 0, proposals are rejected if their total price, less this much
+	// gas at the current network base fee, is below MinDealNetRevenue. Only
+	// the client payment counts as revenue, so zero priced deals are rejected
+	// while this is enabled. 0 = disabled
+	DealCommitGas int64
+	// The minimum expected net revenue of a storage deal, see DealCommitGas
+	MinDealNetRevenue types.FIL
+
+	// HTTP endpoints which receive a POST with a JSON summary of a storage
+	// deal when it is accepted, its sector is sealed, it becomes active,
+	// expires or is slashed, or it fails after being accepted
+	Webhooks []string
+	// If not empty, webhook payloads are signed with HMAC-SHA256 using this
+	// secret, and the hex encoded signature is sent in the X-Lotus-Signature
+	// header
+	WebhookSecret string
+
 	Filter          string
 	RetrievalFilter string
 
@@ -364,6 +390,9 @@ func DefaultStorageMiner() *StorageMiner {
 			ConsiderVerifiedStorageDeals:   true,
 			ConsiderUnverifiedStorageDeals: true,
 			PieceCidBlocklist:              []cid.Cid{},
+			ClientAddressBlocklist:         []string{},
+			ClientAddressAllowlist:         []string{},
+			Webhooks:                       []string{},
 			// TODO: It'd be nice to set this based on sector size
 			MaxDealStartDelay:               Duration(time.Hour * 24 * 14),
 			ExpectedSealDuration:            Duration(time.Hour * 24),
@@ -372,6 +401,7 @@ func DefaultStorageMiner() *StorageMiner {
 			MaxProviderCollateralMultiplier: 2,
 
 			SimultaneousTransfers: DefaultSimultaneousTransfers,
+			MinDealNetRevenue:     types.MustParseFIL("0"),
 
 			RetrievalPricing: &RetrievalPricing{
 				Strategy: RetrievalPricingDefaultMode,
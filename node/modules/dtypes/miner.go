@@ -2,6 +2,7 @@ package dtypes
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	"github.com/ipfs/go-cid"
@@ -42,6 +43,16 @@ type StorageDealPieceCidBlocklistConfigFunc func() ([]cid.Cid, error)
 // list of CIDs for which the miner will reject deal proposals.
 type SetStorageDealPieceCidBlocklistConfigFunc func([]cid.Cid) error
 
+// StorageDealClientBlocklistConfigFunc is a function which reads from miner
+// config to obtain a list of client addresses from which the miner will not
+// accept storage proposals.
+type StorageDealClientBlocklistConfigFunc func() ([]address.Address, error)
+
+// StorageDealClientAllowlistConfigFunc is a function which reads from miner
+// config to obtain a list of client addresses from which the miner will accept
+// storage proposals. An empty list allows all clients.
+type StorageDealClientAllowlistConfigFunc func() ([]address.Address, error)
+
 // ConsiderOfflineStorageDealsConfigFunc is a function which reads from miner
 // config to determine if the user has disabled storage deals (or not).
 type ConsiderOfflineStorageDealsConfigFunc func() (bool, error)
@@ -93,6 +104,67 @@ type GetExpectedSealDurationFunc func() (time.Duration, error)
 type SetMaxDealStartDelayFunc func(time.Duration) error
 type GetMaxDealStartDelayFunc func() (time.Duration, error)
 
+// GetMaxActiveDealsFunc is a function which reads from miner config the
+// maximum number of storage deals which may be processed at once.
+type GetMaxActiveDealsFunc func() (uint64, error)
+
+// DealGasConfig is the gas model used to reject storage deals which are not
+// expected to pay for the gas needed to commit them.
+type DealGasConfig struct {
+	// CommitGas is the gas expected to be used committing a deal, 0 disables
+	// the check
+	CommitGas int64
+	// MinNetRevenue is the minimum deal price left after paying for CommitGas
+	MinNetRevenue abi.TokenAmount
+}
+
+// GetDealGasConfigFunc is a function which reads the DealGasConfig from miner
+// config.
+type GetDealGasConfigFunc func() (DealGasConfig, error)
+
+// ActiveStorageDeals tracks the storage deals which the provider is still
+// processing. It is updated from storage provider events and consulted by
+// the deal filter to enforce Dealmaking.MaxActiveDeals.
+type ActiveStorageDeals struct {
+	lk    sync.Mutex
+	deals map[cid.Cid]struct{}
+}
+
+func NewActiveStorageDeals() *ActiveStorageDeals {
+	return &ActiveStorageDeals{
+		deals: map[cid.Cid]struct{}{},
+	}
+}
+
+// Add marks the deal with the given proposal CID as active.
+func (a *ActiveStorageDeals) Add(proposalCid cid.Cid) {
+	a.lk.Lock()
+	defer a.lk.Unlock()
+
+	a.deals[proposalCid] = struct{}{}
+}
+
+// Remove marks the deal with the given proposal CID as no longer active.
+func (a *ActiveStorageDeals) Remove(proposalCid cid.Cid) {
+	a.lk.Lock()
+	defer a.lk.Unlock()
+
+	delete(a.deals, proposalCid)
+}
+
+// CountExcept returns the number of active deals, not counting the deal with
+// the given proposal CID.
+func (a *ActiveStorageDeals) CountExcept(proposalCid cid.Cid) int {
+	a.lk.Lock()
+	defer a.lk.Unlock()
+
+	n := len(a.deals)
+	if _, ok := a.deals[proposalCid]; ok {
+		n--
+	}
+	return n
+}
+
 type StorageDealFilter func(ctx context.Context, deal storagemarket.MinerDeal) (bool, string, error)
 type RetrievalDealFilter func(ctx context.Context, deal retrievalmarket.ProviderDealState) (bool, string, error)
 
@@ -0,0 +1,36 @@
+package dtypes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-state-types/abi"
+)
+
+func TestActiveStorageDealsCountExcept(t *testing.T) {
+	a := NewActiveStorageDeals()
+
+	c1, err := abi.CidBuilder.Sum([]byte("deal 1"))
+	require.NoError(t, err)
+	c2, err := abi.CidBuilder.Sum([]byte("deal 2"))
+	require.NoError(t, err)
+	c3, err := abi.CidBuilder.Sum([]byte("deal 3"))
+	require.NoError(t, err)
+
+	require.Equal(t, 0, a.CountExcept(c1))
+
+	a.Add(c1)
+	a.Add(c2)
+	a.Add(c2) // adding twice doesn't count twice
+
+	// the deal being considered is in the set
+	require.Equal(t, 1, a.CountExcept(c1))
+	// the deal being considered is not in the set
+	require.Equal(t, 2, a.CountExcept(c3))
+
+	a.Remove(c1)
+	a.Remove(c3) // removing an unknown deal is a no-op
+	require.Equal(t, 1, a.CountExcept(c3))
+	require.Equal(t, 0, a.CountExcept(c2))
+}
@@ -46,6 +46,7 @@ import (
 	"github.com/filecoin-project/go-multistore"
 	paramfetch "github.com/filecoin-project/go-paramfetch"
 	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
 	"github.com/filecoin-project/go-statestore"
 	"github.com/filecoin-project/go-storedcounter"
 
@@ -66,8 +67,10 @@ import (
 	"github.com/filecoin-project/lotus/chain/types"
 	"github.com/filecoin-project/lotus/journal"
 	"github.com/filecoin-project/lotus/markets"
+	"github.com/filecoin-project/lotus/markets/dealfilter"
 	marketevents "github.com/filecoin-project/lotus/markets/loggers"
 	"github.com/filecoin-project/lotus/markets/retrievaladapter"
+	"github.com/filecoin-project/lotus/markets/webhooks"
 	lotusminer "github.com/filecoin-project/lotus/miner"
 	"github.com/filecoin-project/lotus/node/config"
 	"github.com/filecoin-project/lotus/node/modules/dtypes"
@@ -273,7 +276,7 @@ func HandleRetrieval(host host.Host, lc fx.Lifecycle, m retrievalmarket.Retrieva
 	})
 }
 
-func HandleDeals(mctx helpers.MetricsCtx, lc fx.Lifecycle, host host.Host, h storagemarket.StorageProvider, j journal.Journal) {
+func HandleDeals(mctx helpers.MetricsCtx, lc fx.Lifecycle, host host.Host, h storagemarket.StorageProvider, j journal.Journal, activeDeals *dtypes.ActiveStorageDeals, wh *webhooks.Notifier) {
 	ctx := helpers.LifecycleCtx(mctx, lc)
 	h.OnReady(marketevents.ReadyLogger("storage provider"))
 	lc.Append(fx.Hook{
@@ -283,6 +286,11 @@ func HandleDeals(mctx helpers.MetricsCtx, lc fx.Lifecycle, host host.Host, h sto
 			evtType := j.RegisterEventType("markets/storage/provider", "state_change")
 			h.SubscribeToEvents(markets.StorageProviderJournaler(j, evtType))
 
+			if err := trackActiveDeals(h, activeDeals); err != nil {
+				return err
+			}
+			h.SubscribeToEvents(wh.OnDealEvent)
+
 			return h.Start(ctx)
 		},
 		OnStop: func(context.Context) error {
@@ -291,6 +299,47 @@ func HandleDeals(mctx helpers.MetricsCtx, lc fx.Lifecycle, host host.Host, h sto
 	})
 }
 
+// inactiveDealStates are the states in which the provider is no longer
+// doing any work for a deal
+var inactiveDealStates = map[storagemarket.StorageDealStatus]struct{}{
+	storagemarket.StorageDealActive:           {},
+	storagemarket.StorageDealExpired:          {},
+	storagemarket.StorageDealSlashed:          {},
+	storagemarket.StorageDealRejecting:        {},
+	storagemarket.StorageDealFailing:          {},
+	storagemarket.StorageDealError:            {},
+	storagemarket.StorageDealProposalRejected: {},
+}
+
+// trackActiveDeals seeds the set of active storage deals with the deals the
+// provider already knows about, and keeps it up to date with provider events.
+func trackActiveDeals(h storagemarket.StorageProvider, activeDeals *dtypes.ActiveStorageDeals) error {
+	deals, err := h.ListLocalDeals()
+	if err != nil {
+		return xerrors.Errorf("listing local deals: %w", err)
+	}
+
+	trackActive := activeDealsTracker(activeDeals)
+	for _, deal := range deals {
+		trackActive(storagemarket.ProviderEventRestart, deal)
+	}
+	h.SubscribeToEvents(trackActive)
+
+	return nil
+}
+
+// activeDealsTracker keeps the set of active storage deals up to date with
+// provider events.
+func activeDealsTracker(activeDeals *dtypes.ActiveStorageDeals) func(storagemarket.ProviderEvent, storagemarket.MinerDeal) {
+	return func(_ storagemarket.ProviderEvent, deal storagemarket.MinerDeal) {
+		if _, inactive := inactiveDealStates[deal.State]; inactive {
+			activeDeals.Remove(deal.ProposalCid)
+			return
+		}
+		activeDeals.Add(deal.ProposalCid)
+	}
+}
+
 func HandleMigrateProviderFunds(lc fx.Lifecycle, ds dtypes.MetadataDS, node api.FullNode, minerAddress dtypes.MinerAddress) {
 	lc.Append(fx.Hook{
 		OnStart: func(ctx context.Context) error {
@@ -487,18 +536,32 @@ func BasicDealFilter(user dtypes.StorageDealFilter) func(onlineOk dtypes.Conside
 	verifiedOk dtypes.ConsiderVerifiedStorageDealsConfigFunc,
 	unverifiedOk dtypes.ConsiderUnverifiedStorageDealsConfigFunc,
 	blocklistFunc dtypes.StorageDealPieceCidBlocklistConfigFunc,
+	clientBlocklistFunc dtypes.StorageDealClientBlocklistConfigFunc,
+	clientAllowlistFunc dtypes.StorageDealClientAllowlistConfigFunc,
 	expectedSealTimeFunc dtypes.GetExpectedSealDurationFunc,
 	startDelay dtypes.GetMaxDealStartDelayFunc,
+	maxActiveFunc dtypes.GetMaxActiveDealsFunc,
+	activeDeals *dtypes.ActiveStorageDeals,
+	gasConfigFunc dtypes.GetDealGasConfigFunc,
+	fapi v1api.FullNode,
 	spn storagemarket.StorageProviderNode) dtypes.StorageDealFilter {
 	return func(onlineOk dtypes.ConsiderOnlineStorageDealsConfigFunc,
 		offlineOk dtypes.ConsiderOfflineStorageDealsConfigFunc,
 		verifiedOk dtypes.ConsiderVerifiedStorageDealsConfigFunc,
 		unverifiedOk dtypes.ConsiderUnverifiedStorageDealsConfigFunc,
 		blocklistFunc dtypes.StorageDealPieceCidBlocklistConfigFunc,
+		clientBlocklistFunc dtypes.StorageDealClientBlocklistConfigFunc,
+		clientAllowlistFunc dtypes.StorageDealClientAllowlistConfigFunc,
 		expectedSealTimeFunc dtypes.GetExpectedSealDurationFunc,
 		startDelay dtypes.GetMaxDealStartDelayFunc,
+		maxActiveFunc dtypes.GetMaxActiveDealsFunc,
+		activeDeals *dtypes.ActiveStorageDeals,
+		gasConfigFunc dtypes.GetDealGasConfigFunc,
+		fapi v1api.FullNode,
 		spn storagemarket.StorageProviderNode) dtypes.StorageDealFilter {
 
+		clientMatcher := dealfilter.NewClientAddressMatcher(fapi)
+
 		return func(ctx context.Context, deal storagemarket.MinerDeal) (bool, string, error) {
 			b, err := onlineOk()
 			if err != nil {
@@ -552,6 +615,54 @@ func BasicDealFilter(user dtypes.StorageDealFilter) func(onlineOk dtypes.Conside
 				}
 			}
 
+			clientBlocklist, err := clientBlocklistFunc()
+			if err != nil {
+				return false, "miner error", err
+			}
+
+			clientAllowlist, err := clientAllowlistFunc()
+			if err != nil {
+				return false, "miner error", err
+			}
+
+			if ok, reason := clientMatcher.Check(ctx, deal.Proposal.Client, clientBlocklist, clientAllowlist); !ok {
+				log.Warnf("%s; rejecting storage deal proposal from client: %s", reason, deal.Client.String())
+				return false, reason, nil
+			}
+
+			maxActive, err := maxActiveFunc()
+			if err != nil {
+				return false, "miner error", err
+			}
+
+			if maxActive > 0 {
+				// the deal being considered is already tracked, don't count it
+				if active := activeDeals.CountExcept(deal.ProposalCid); uint64(active) >= maxActive {
+					log.Warnw("too many active storage deals; rejecting storage deal proposal from client", "client", deal.Client.String(), "active", active, "max", maxActive)
+					return false, fmt.Sprintf("miner is at capacity with %d active deals, retry later", active), nil
+				}
+			}
+
+			gasCfg, err := gasConfigFunc()
+			if err != nil {
+				return false, "miner error", err
+			}
+
+			if gasCfg.CommitGas > 0 {
+				ts, err := fapi.ChainHead(ctx)
+				if err != nil {
+					return false, "failed to get chain head", err
+				}
+
+				baseFee := ts.MinTicketBlock().ParentBaseFee
+				gasCost := types.BigMul(baseFee, big.NewInt(gasCfg.CommitGas))
+				revenue := types.BigMul(deal.Proposal.StoragePricePerEpoch, big.NewInt(int64(deal.Proposal.Duration())))
+				if net := types.BigSub(revenue, gasCost); net.LessThan(gasCfg.MinNetRevenue) {
+					log.Warnw("proposed deal would not pay for the expected commit gas; rejecting storage deal proposal from client", "client", deal.Client.String(), "price", types.FIL(revenue), "gas_cost", types.FIL(gasCost), "base_fee", baseFee, "min_net_revenue", types.FIL(gasCfg.MinNetRevenue))
+					return false, fmt.Sprintf("deal price %s does not cover the expected commit gas cost %s at the current base fee, retry later", types.FIL(revenue), types.FIL(gasCost)), nil
+				}
+			}
+
 			sealDuration, err := expectedSealTimeFunc()
 			if err != nil {
 				return false, "miner error", err
@@ -778,6 +889,58 @@ func NewSetStorageDealPieceCidBlocklistConfigFunc(r repo.LockedRepo) (dtypes.Set
 	}, nil
 }
 
+func NewStorageDealClientBlocklistConfigFunc(r repo.LockedRepo) (dtypes.StorageDealClientBlocklistConfigFunc, error) {
+	get := func() (out []address.Address, err error) {
+		var addrs []string
+		err = readCfg(r, func(cfg *config.StorageMiner) {
+			addrs = cfg.Dealmaking.ClientAddressBlocklist
+		})
+		if err != nil {
+			return nil, err
+		}
+		return parseClientAddresses(addrs)
+	}
+
+	// fail on startup rather than on the first deal if the list is malformed
+	if _, err := get(); err != nil {
+		return nil, xerrors.Errorf("invalid Dealmaking.ClientAddressBlocklist: %w", err)
+	}
+
+	return get, nil
+}
+
+func NewStorageDealClientAllowlistConfigFunc(r repo.LockedRepo) (dtypes.StorageDealClientAllowlistConfigFunc, error) {
+	get := func() (out []address.Address, err error) {
+		var addrs []string
+		err = readCfg(r, func(cfg *config.StorageMiner) {
+			addrs = cfg.Dealmaking.ClientAddressAllowlist
+		})
+		if err != nil {
+			return nil, err
+		}
+		return parseClientAddresses(addrs)
+	}
+
+	// fail on startup rather than on the first deal if the list is malformed
+	if _, err := get(); err != nil {
+		return nil, xerrors.Errorf("invalid Dealmaking.ClientAddressAllowlist: %w", err)
+	}
+
+	return get, nil
+}
+
+func parseClientAddresses(addrs []string) ([]address.Address, error) {
+	out := make([]address.Address, 0, len(addrs))
+	for _, s := range addrs {
+		a, err := address.NewFromString(s)
+		if err != nil {
+			return nil, xerrors.Errorf("parsing client address %q: %w", s, err)
+		}
+		out = append(out, a)
+	}
+	return out, nil
+}
+
 func NewConsiderOfflineStorageDealsConfigFunc(r repo.LockedRepo) (dtypes.ConsiderOfflineStorageDealsConfigFunc, error) {
 	return func() (out bool, err error) {
 		err = readCfg(r, func(cfg *config.StorageMiner) {
@@ -954,6 +1117,27 @@ func NewGetMaxDealStartDelayFunc(r repo.LockedRepo) (dtypes.GetMaxDealStartDelay
 	}, nil
 }
 
+func NewGetMaxActiveDealsFunc(r repo.LockedRepo) (dtypes.GetMaxActiveDealsFunc, error) {
+	return func() (out uint64, err error) {
+		err = readCfg(r, func(cfg *config.StorageMiner) {
+			out = cfg.Dealmaking.MaxActiveDeals
+		})
+		return
+	}, nil
+}
+
+func NewGetDealGasConfigFunc(r repo.LockedRepo) (dtypes.GetDealGasConfigFunc, error) {
+	return func() (out dtypes.DealGasConfig, err error) {
+		err = readCfg(r, func(cfg *config.StorageMiner) {
+			out = dtypes.DealGasConfig{
+				CommitGas:     cfg.Dealmaking.DealCommitGas,
+				MinNetRevenue: abi.TokenAmount(cfg.Dealmaking.MinDealNetRevenue),
+			}
+		})
+		return
+	}, nil
+}
+
 func readCfg(r repo.LockedRepo, accessor func(*config.StorageMiner)) error {
 	raw, err := r.Config()
 	if err != nil {
@@ -0,0 +1,231 @@
+package modules
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-fil-markets/shared"
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/api/v1api"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/chain/types/mock"
+	"github.com/filecoin-project/lotus/node/modules/dtypes"
+)
+
+func mkProposalCid(t *testing.T, s string) cid.Cid {
+	c, err := abi.CidBuilder.Sum([]byte(s))
+	require.NoError(t, err)
+	return c
+}
+
+// fakeProvider implements the parts of the storage provider used to track
+// active deals
+type fakeProvider struct {
+	storagemarket.StorageProvider
+
+	deals       []storagemarket.MinerDeal
+	subscribers []storagemarket.ProviderSubscriber
+}
+
+func (p *fakeProvider) ListLocalDeals() ([]storagemarket.MinerDeal, error) {
+	return p.deals, nil
+}
+
+func (p *fakeProvider) SubscribeToEvents(subscriber storagemarket.ProviderSubscriber) shared.Unsubscribe {
+	p.subscribers = append(p.subscribers, subscriber)
+	return func() {}
+}
+
+func (p *fakeProvider) publish(deal storagemarket.MinerDeal) {
+	for _, s := range p.subscribers {
+		s(storagemarket.ProviderEventOpen, deal)
+	}
+}
+
+func TestTrackActiveDeals(t *testing.T) {
+	sealing := storagemarket.MinerDeal{ProposalCid: mkProposalCid(t, "sealing"), State: storagemarket.StorageDealSealing}
+	active := storagemarket.MinerDeal{ProposalCid: mkProposalCid(t, "active"), State: storagemarket.StorageDealActive}
+	failed := storagemarket.MinerDeal{ProposalCid: mkProposalCid(t, "failed"), State: storagemarket.StorageDealError}
+	other := mkProposalCid(t, "other")
+
+	p := &fakeProvider{deals: []storagemarket.MinerDeal{sealing, active, failed}}
+	activeDeals := dtypes.NewActiveStorageDeals()
+	require.NoError(t, trackActiveDeals(p, activeDeals))
+
+	// only the deal still in progress is seeded from the local deals
+	require.Equal(t, 1, activeDeals.CountExcept(other))
+	require.Equal(t, 0, activeDeals.CountExcept(sealing.ProposalCid))
+
+	// events update the set
+	proposed := storagemarket.MinerDeal{ProposalCid: mkProposalCid(t, "proposed"), State: storagemarket.StorageDealValidating}
+	p.publish(proposed)
+	require.Equal(t, 2, activeDeals.CountExcept(other))
+}
+
+func TestActiveDealsTrackerTerminalStates(t *testing.T) {
+	other := mkProposalCid(t, "other")
+
+	for st := range inactiveDealStates {
+		st := st
+		t.Run(storagemarket.DealStates[st], func(t *testing.T) {
+			activeDeals := dtypes.NewActiveStorageDeals()
+			track := activeDealsTracker(activeDeals)
+
+			deal := storagemarket.MinerDeal{ProposalCid: mkProposalCid(t, "deal"), State: storagemarket.StorageDealTransferring}
+			track(storagemarket.ProviderEventOpen, deal)
+			require.Equal(t, 1, activeDeals.CountExcept(other))
+
+			deal.State = st
+			track(storagemarket.ProviderEventOpen, deal)
+			require.Equal(t, 0, activeDeals.CountExcept(other))
+		})
+	}
+}
+
+// fakeProviderNode implements the parts of the storage provider node used by
+// the deal filter
+type fakeProviderNode struct {
+	storagemarket.StorageProviderNode
+
+	height abi.ChainEpoch
+}
+
+func (n *fakeProviderNode) GetChainHead(ctx context.Context) (shared.TipSetToken, abi.ChainEpoch, error) {
+	return nil, n.height, nil
+}
+
+// fakeFullNode implements the parts of the full node API used by the deal
+// filter
+type fakeFullNode struct {
+	v1api.FullNode
+
+	baseFee abi.TokenAmount
+}
+
+func (n *fakeFullNode) ChainHead(ctx context.Context) (*types.TipSet, error) {
+	blk := mock.MkBlock(nil, 1, 1)
+	blk.ParentBaseFee = n.baseFee
+	return mock.TipSet(blk), nil
+}
+
+func testDealFilter(maxActive uint64, activeDeals *dtypes.ActiveStorageDeals, gasCfg dtypes.DealGasConfig, fapi v1api.FullNode) dtypes.StorageDealFilter {
+	yes := func() (bool, error) { return true, nil }
+
+	return BasicDealFilter(nil)(
+		yes, yes, yes, yes,
+		func() ([]cid.Cid, error) { return nil, nil },
+		func() ([]address.Address, error) { return nil, nil },
+		func() ([]address.Address, error) { return nil, nil },
+		func() (time.Duration, error) { return 0, nil },
+		func() (time.Duration, error) { return time.Hour, nil },
+		func() (uint64, error) { return maxActive, nil },
+		activeDeals,
+		func() (dtypes.DealGasConfig, error) { return gasCfg, nil },
+		fapi,
+		&fakeProviderNode{height: 100},
+	)
+}
+
+func TestBasicDealFilterMaxActiveDeals(t *testing.T) {
+	ctx := context.Background()
+
+	activeDeals := dtypes.NewActiveStorageDeals()
+	activeDeals.Add(mkProposalCid(t, "deal 1"))
+	activeDeals.Add(mkProposalCid(t, "deal 2"))
+
+	mkDeal := func(s string) storagemarket.MinerDeal {
+		var deal storagemarket.MinerDeal
+		deal.ProposalCid = mkProposalCid(t, s)
+		deal.Proposal.StartEpoch = 150
+		return deal
+	}
+
+	tcs := map[string]struct {
+		maxActive uint64
+		deal      storagemarket.MinerDeal
+		accept    bool
+		reason    string
+	}{
+		"no limit":           {maxActive: 0, deal: mkDeal("new"), accept: true},
+		"below limit":        {maxActive: 3, deal: mkDeal("new"), accept: true},
+		"at limit":           {maxActive: 2, deal: mkDeal("new"), reason: "miner is at capacity with 2 active deals, retry later"},
+		"deal already known": {maxActive: 2, deal: mkDeal("deal 2"), accept: true},
+	}
+
+	for name, tc := range tcs {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			accept, reason, err := testDealFilter(tc.maxActive, activeDeals, dtypes.DealGasConfig{}, nil)(ctx, tc.deal)
+			require.NoError(t, err)
+			require.Equal(t, tc.accept, accept)
+			require.Equal(t, tc.reason, reason)
+		})
+	}
+}
+
+func TestBasicDealFilterCommitGas(t *testing.T) {
+	ctx := context.Background()
+
+	// 1000 gas at a base fee of 100 attoFIL costs 100000 attoFIL
+	fapi := &fakeFullNode{baseFee: abi.NewTokenAmount(100)}
+	gasCost := "0.0000000000001 FIL"
+
+	mkDeal := func(price int64) storagemarket.MinerDeal {
+		var deal storagemarket.MinerDeal
+		deal.ProposalCid = mkProposalCid(t, "deal")
+		deal.Proposal.StartEpoch = 150
+		deal.Proposal.EndEpoch = 1150
+		deal.Proposal.StoragePricePerEpoch = abi.NewTokenAmount(price)
+		return deal
+	}
+
+	tcs := map[string]struct {
+		gasCfg dtypes.DealGasConfig
+		deal   storagemarket.MinerDeal
+		accept bool
+		reason string
+	}{
+		"disabled": {
+			gasCfg: dtypes.DealGasConfig{MinNetRevenue: abi.NewTokenAmount(0)},
+			deal:   mkDeal(0),
+			accept: true,
+		},
+		"covers gas": {
+			gasCfg: dtypes.DealGasConfig{CommitGas: 1000, MinNetRevenue: abi.NewTokenAmount(0)},
+			deal:   mkDeal(200),
+			accept: true,
+		},
+		"exactly covers gas": {
+			gasCfg: dtypes.DealGasConfig{CommitGas: 1000, MinNetRevenue: abi.NewTokenAmount(0)},
+			deal:   mkDeal(100),
+			accept: true,
+		},
+		"negative net revenue": {
+			gasCfg: dtypes.DealGasConfig{CommitGas: 1000, MinNetRevenue: abi.NewTokenAmount(0)},
+			deal:   mkDeal(50),
+			reason: "deal price 0.00000000000005 FIL does not cover the expected commit gas cost " + gasCost + " at the current base fee, retry later",
+		},
+		"below minimum net revenue": {
+			gasCfg: dtypes.DealGasConfig{CommitGas: 1000, MinNetRevenue: abi.NewTokenAmount(150000)},
+			deal:   mkDeal(200),
+			reason: "deal price 0.0000000000002 FIL does not cover the expected commit gas cost " + gasCost + " at the current base fee, retry later",
+		},
+	}
+
+	for name, tc := range tcs {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			accept, reason, err := testDealFilter(0, dtypes.NewActiveStorageDeals(), tc.gasCfg, fapi)(ctx, tc.deal)
+			require.NoError(t, err)
+			require.Equal(t, tc.accept, accept)
+			require.Equal(t, tc.reason, reason)
+		})
+	}
+}